@@ -3,9 +3,13 @@ package bindata
 import (
 	"bytes"
 	"errors"
+	"io"
+	"io/fs"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 )
@@ -24,18 +28,31 @@ type dir struct {
 
 // FileSystem interface
 
-func (d *dir) Open(path string) (http.File, error) {
-	path = strings.TrimLeft(filepath.Clean(path), string([]rune{filepath.Separator}))
-	//log.Printf("bindata: open %q", path)
+func (d *dir) Open(name string) (http.File, error) {
+	rel, err := resolveVirtualPath(name)
+	if err != nil {
+		return nil, &os.PathError{"open", name, err}
+	}
+
+	f, sub, err := d.lookup(rel)
+	if err != nil {
+		return nil, &os.PathError{"open", name, err}
+	}
+	if sub != nil {
+		return newDirHandle(sub), nil
+	}
+	return f, nil
+}
 
-	switch path {
-	case "", "..":
-		return nil, &os.PathError{"open", path, os.ErrNotExist}
-	case ".":
-		return d, nil
+// lookup resolves the already-cleaned, slash-separated path rel to
+// either a file or a subdirectory rooted at d. Exactly one of f and sub
+// is non-nil on success.
+func (d *dir) lookup(rel string) (f *file, sub *dir, err error) {
+	if rel == "." {
+		return nil, d, nil
 	}
 
-	components := strings.Split(path, string([]rune{os.PathSeparator}))
+	components := strings.Split(rel, "/")
 	current := d
 
 	for i, c := range components {
@@ -43,42 +60,68 @@ func (d *dir) Open(path string) (http.File, error) {
 			// is a directory
 			if current.dirs == nil {
 				// current dir has no subdirs
-				return nil, &os.PathError{"open", path, os.ErrNotExist}
+				return nil, nil, os.ErrNotExist
 			}
 			if dd, ok := current.dirs[c]; ok {
 				current = dd
 			} else {
 				// current dir has no such subdir
-				return nil, &os.PathError{"open", path, os.ErrNotExist}
+				return nil, nil, os.ErrNotExist
 			}
 		} else {
 			// is the target file or directory
 			if current.files != nil {
-				if f := current.file(c); f != nil {
-					return f, nil
+				if cf := current.file(c); cf != nil {
+					return cf, nil, nil
 				}
 			}
 			if current.dirs != nil {
-				if d, ok := current.dirs[c]; ok {
-					return d, nil
+				if dd, ok := current.dirs[c]; ok {
+					return nil, dd, nil
 				}
 			}
-			return nil, &os.PathError{"open", path, os.ErrNotExist}
+			return nil, nil, os.ErrNotExist
 		}
 	}
 
-	return nil, os.ErrNotExist
+	return nil, nil, os.ErrNotExist
+}
+
+// resolveVirtualPath cleans name the same way vfs.resolvePath does:
+// always through the "path" package, so "/" is the only separator
+// regardless of GOOS, and any ".." left over after cleaning is rejected.
+// bindata's tree is always addressed with forward slashes -- that's what
+// the generator emits -- so splitting on the OS's native separator, as
+// Open used to, mishandled names on platforms where that isn't "/".
+func resolveVirtualPath(name string) (string, error) {
+	if strings.IndexByte(name, 0) >= 0 {
+		return "", fs.ErrInvalid
+	}
+	clean := path.Clean(strings.TrimLeft(name, "/"))
+	if clean == "" {
+		clean = "."
+	}
+	for _, part := range strings.Split(clean, "/") {
+		if part == ".." {
+			return "", fs.ErrInvalid
+		}
+	}
+	return clean, nil
 }
 
 func (d *dir) Walk(path string, fn filepath.WalkFunc) error {
-	targetDir, err := d.Open(path)
+	rel, err := resolveVirtualPath(path)
+	if err != nil {
+		return &os.PathError{"walk", path, err}
+	}
+	_, sub, err := d.lookup(rel)
 	if err != nil {
-		return err
+		return &os.PathError{"walk", path, err}
 	}
-	if x, ok := targetDir.(*dir); ok {
-		return x.walk(path, fn)
+	if sub == nil {
+		return &os.PathError{"walk", path, errIsFile}
 	}
-	return &os.PathError{"walk", path, errIsFile}
+	return sub.walk(path, fn)
 }
 
 // recursive; never returns an error
@@ -109,17 +152,6 @@ func (d *dir) Read(p []byte) (int, error) {
 	return 0, &os.PathError{"read", d.name, errIsDirectory}
 }
 
-func (d *dir) Readdir(count int) ([]os.FileInfo, error) {
-	fis := make([]os.FileInfo, 0, len(d.files)+len(d.dirs))
-	for name := range d.files {
-		fis = append(fis, d.file(name))
-	}
-	for _, dir := range d.dirs {
-		fis = append(fis, dir)
-	}
-	return fis, nil
-}
-
 func (d *dir) Seek(offset int64, whence int) (int64, error) {
 	return 0, &os.PathError{"seek", d.name, errIsDirectory}
 }
@@ -137,6 +169,70 @@ func (d *dir) ModTime() time.Time { return startupTime }
 func (d *dir) IsDir() bool        { return true }
 func (d *dir) Sys() interface{}   { return d }
 
+// dirHandle is the http.File Open returns for a directory: a per-open
+// view of dir with its own Readdir/ReadDir cursor, so two concurrent
+// Opens of the same directory don't share a read position. A registered
+// root's tree never changes after it's built (see root.resolve), so the
+// name listing can be sorted once, up front, when the directory is
+// opened, rather than snapshotted on first read the way memFS's is.
+type dirHandle struct {
+	*dir
+
+	names  []string
+	offset int
+}
+
+func newDirHandle(d *dir) *dirHandle {
+	names := make([]string, 0, len(d.files)+len(d.dirs))
+	for name := range d.files {
+		names = append(names, name)
+	}
+	for name := range d.dirs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return &dirHandle{dir: d, names: names}
+}
+
+func (h *dirHandle) readdir(count int) ([]os.FileInfo, error) {
+	remaining := len(h.names) - h.offset
+	n := count
+	if n <= 0 || n > remaining {
+		n = remaining
+	}
+	names := h.names[h.offset : h.offset+n]
+	h.offset += n
+
+	fis := make([]os.FileInfo, 0, len(names))
+	for _, name := range names {
+		if f := h.dir.file(name); f != nil {
+			fis = append(fis, f)
+		} else if dd, ok := h.dir.dirs[name]; ok {
+			fis = append(fis, dd)
+		}
+	}
+	if count > 0 && len(fis) == 0 {
+		return fis, io.EOF
+	}
+	return fis, nil
+}
+
+func (h *dirHandle) Readdir(count int) ([]os.FileInfo, error) {
+	return h.readdir(count)
+}
+
+// ReadDir implements fs.ReadDirFile, letting callers that only need names
+// and modes (e.g. io/fs walkers) skip the os.FileInfo conversion Readdir
+// does for every entry.
+func (h *dirHandle) ReadDir(count int) ([]fs.DirEntry, error) {
+	fis, err := h.readdir(count)
+	entries := make([]fs.DirEntry, len(fis))
+	for i, fi := range fis {
+		entries[i] = fs.FileInfoToDirEntry(fi)
+	}
+	return entries, err
+}
+
 func (d *dir) file(name string) *file {
 	f, ok := d.files[name]
 	if !ok {
@@ -146,13 +242,58 @@ func (d *dir) file(name string) *file {
 	return &file{
 		name:   f.name,
 		mod:    f.mod,
+		mode:   f.mode,
+		sum:    f.sum,
 		Reader: bytes.NewReader(f.data),
 	}
 }
 
+// Hash implements vfs.Hasher. Every registered file's sha256 digest is
+// computed once, the first time its root is resolved (see registry.go),
+// so Hash itself never reads or hashes anything.
+func (d *dir) Hash(name string) (alg string, sum []byte, ok bool) {
+	f, err := d.Open(name)
+	if err != nil {
+		return "", nil, false
+	}
+	defer f.Close()
+	bf, isFile := f.(*file)
+	if !isFile || bf.sum == nil {
+		return "", nil, false
+	}
+	return "sha256", bf.sum, true
+}
+
+// put inserts a file at the slash-separated path rel, creating any
+// intermediate directories as needed. It is used by the registry to build
+// a dir tree out of a registered root's flat entry table.
+func (d *dir) put(rel string, f *file) {
+	rel = strings.TrimPrefix(path.Clean("/"+rel), "/")
+	parts := strings.Split(rel, "/")
+	current := d
+	for _, part := range parts[:len(parts)-1] {
+		if current.dirs == nil {
+			current.dirs = map[string]*dir{}
+		}
+		next, ok := current.dirs[part]
+		if !ok {
+			next = &dir{name: part}
+			current.dirs[part] = next
+		}
+		current = next
+	}
+	if current.files == nil {
+		current.files = map[string]*file{}
+	}
+	f.name = parts[len(parts)-1]
+	current.files[f.name] = f
+}
+
 type file struct {
 	name string
 	mod  time.Time
+	mode os.FileMode
+	sum  []byte // sha256 digest, computed once when the root is resolved
 
 	// sort of like a union (either Reader when opened for reading or []byte
 	// for storage)
@@ -178,8 +319,15 @@ func (f *file) Stat() (os.FileInfo, error) {
 // os.FileInfo interface
 // Size() int64 is implemented in *bytes.Reader
 
-func (f *file) Name() string       { return f.name }
-func (f *file) Mode() os.FileMode  { return 0400 }
+func (f *file) Name() string { return f.name }
+
+func (f *file) Mode() os.FileMode {
+	if f.mode == 0 {
+		return 0400
+	}
+	return f.mode
+}
+
 func (f *file) ModTime() time.Time { return f.mod }
 func (f *file) IsDir() bool        { return false }
 func (f *file) Sys() interface{}   { return f }
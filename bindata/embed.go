@@ -0,0 +1,17 @@
+package bindata
+
+import (
+	"io/fs"
+
+	"ktkr.us/pkg/vfs"
+)
+
+// FromEmbed adapts an embed.FS (as produced by the bindata tool's
+// -mode=embed output) to vfs.FileSystem, for callers who'd rather ship a
+// self-contained Go 1.16+ embed.FS than the tool's own compressed blob
+// format. fsys is typed as fs.FS rather than embed.FS so the generated
+// init code can pass the result of fs.Sub (stripping the embedded
+// directory's own name, to match the rooting RegisterFile's roots use).
+func FromEmbed(fsys fs.FS) vfs.FileSystem {
+	return vfs.FromFS(fsys)
+}
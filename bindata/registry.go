@@ -0,0 +1,150 @@
+package bindata
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"ktkr.us/pkg/vfs"
+)
+
+var startupTime = time.Now()
+
+// Entry describes one file packed into a registered root's blob: the
+// slash-separated path it should appear at, and the byte range within the
+// (decompressed) blob holding its contents.
+type Entry struct {
+	Name    string
+	Offset  int64
+	Length  int64
+	ModTime time.Time
+	Mode    os.FileMode
+}
+
+// root is a registered top-level directory. It is either a compressed
+// blob plus a table describing how to slice it into files, decoded lazily
+// the first time Root is called for it, or an already-resolved
+// FileSystem (as registered by RegisterFS for embed.FS-backed roots).
+type root struct {
+	compress string
+	blob     []byte
+	entries  []Entry
+	resolved vfs.FileSystem
+
+	once sync.Once
+	tree vfs.FileSystem
+	err  error
+}
+
+var (
+	rootsMu sync.Mutex
+	roots   = map[string]*root{}
+)
+
+// RegisterFile registers a root directory generated by the bindata tool.
+// blob holds every file under the root concatenated together and encoded
+// with compress ("", "gzip", or "zstd"); entries describes the byte range
+// each file occupies once blob has been decompressed. Decompression and
+// tree construction happen once, on the first Root call for name, not at
+// init time, so a binary embedding many roots only pays for the ones it
+// looks up. They are not deferred further to the first Open on the
+// returned FileSystem.
+func RegisterFile(name, compress string, blob []byte, entries []Entry) {
+	rootsMu.Lock()
+	defer rootsMu.Unlock()
+	roots[name] = &root{compress: compress, blob: blob, entries: entries}
+}
+
+// MustDecodeBlob base64-decodes s, which the bindata tool emits as a
+// generated root's blob literal: base64 text is far denser per source
+// byte than a composite literal of "0x%02x," bytes, and unlike a
+// \xNN-escaped string literal it never makes the Go scanner tokenize
+// non-UTF-8 escapes. It panics on malformed input, which only a hand-edit
+// of generated code should ever produce.
+func MustDecodeBlob(s string) []byte {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		panic("bindata: malformed blob literal: " + err.Error())
+	}
+	return b
+}
+
+// RegisterFS registers an already-resolved FileSystem under name, for
+// roots produced with the bindata tool's -mode=embed output (see
+// FromEmbed).
+func RegisterFS(name string, fsys vfs.FileSystem) {
+	rootsMu.Lock()
+	defer rootsMu.Unlock()
+	roots[name] = &root{resolved: fsys}
+}
+
+// Root returns the FileSystem registered under name, decoding its blob if
+// this is the first call for that root. It returns os.ErrNotExist if
+// nothing was registered under name.
+func Root(name string) (vfs.FileSystem, error) {
+	rootsMu.Lock()
+	r, ok := roots[name]
+	rootsMu.Unlock()
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return r.resolve()
+}
+
+func (r *root) resolve() (vfs.FileSystem, error) {
+	if r.resolved != nil {
+		return r.resolved, nil
+	}
+
+	r.once.Do(func() {
+		data, err := decompress(r.compress, r.blob)
+		if err != nil {
+			r.err = err
+			return
+		}
+
+		tree := &dir{name: "."}
+		for _, e := range r.entries {
+			if e.Offset < 0 || e.Length < 0 || e.Offset+e.Length > int64(len(data)) {
+				r.err = fmt.Errorf("bindata: entry %q out of range of its root's blob", e.Name)
+				return
+			}
+			content := data[e.Offset : e.Offset+e.Length]
+			sum := sha256.Sum256(content)
+			tree.put(e.Name, &file{mod: e.ModTime, mode: e.Mode, data: content, sum: sum[:]})
+		}
+		r.tree = tree
+	})
+
+	return r.tree, r.err
+}
+
+func decompress(codec string, blob []byte) ([]byte, error) {
+	switch codec {
+	case "", "none":
+		return blob, nil
+	case "gzip":
+		zr, err := gzip.NewReader(bytes.NewReader(blob))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		return io.ReadAll(zr)
+	case "zstd":
+		zr, err := zstd.NewReader(bytes.NewReader(blob))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		return io.ReadAll(zr)
+	default:
+		return nil, fmt.Errorf("bindata: unknown compression codec %q", codec)
+	}
+}
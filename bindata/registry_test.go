@@ -0,0 +1,41 @@
+package bindata
+
+import (
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"ktkr.us/pkg/vfs"
+)
+
+func TestRootConformsToFstest(t *testing.T) {
+	now := time.Now()
+	RegisterFile("fstest-root", "", []byte("ab"), []Entry{
+		{Name: "a.txt", Offset: 0, Length: 1, ModTime: now, Mode: 0644},
+		{Name: "sub/b.txt", Offset: 1, Length: 1, ModTime: now, Mode: 0644},
+	})
+
+	root, err := Root("fstest-root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(vfs.AsFS(root), "a.txt", "sub/b.txt"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMustDecodeBlob(t *testing.T) {
+	got := MustDecodeBlob("aGVsbG8=")
+	if string(got) != "hello" {
+		t.Errorf("MustDecodeBlob(%q) = %q, want %q", "aGVsbG8=", got, "hello")
+	}
+}
+
+func TestMustDecodeBlobPanicsOnMalformedInput(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("MustDecodeBlob did not panic on malformed base64")
+		}
+	}()
+	MustDecodeBlob("not valid base64!!")
+}
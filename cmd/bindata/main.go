@@ -1,39 +1,56 @@
 // to include binary data in an application, put
 //
-//     //go:generate bindata static templates
+//	//go:generate bindata static templates
 //
 // somewhere in the application code and run
 //
-//     $ go generate
+//	$ go generate
 //
 // every time the files change before building.
+//
+// By default, bindata packs each argument directory into a single
+// compressed blob plus a table of the files it contains, registered with
+// bindata.RegisterFile and decoded lazily on first use. Passing
+// -mode=embed instead emits a //go:embed directive per directory and
+// registers the resulting embed.FS with bindata.RegisterFS, for callers
+// who'd rather ship a self-contained Go 1.16+ embed.FS.
 package main
 
 import (
 	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
 	"flag"
 	"fmt"
 	"go/build"
 	"io"
+	"io/ioutil"
 	"log"
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"ktkr.us/pkg/vfs"
 )
 
 const (
-	pkgName    = "bindata_files"
-	fileName   = "bindata.go"
+	pkgName  = "bindata_files"
+	fileName = "bindata.go"
+
 	importFile = `package %s
 
 import _ "%s"
 `
-	dataFilePrefix = `package %s
+
+	blobFilePrefix = `package %s
 
 import (
-	"path/filepath"
 	"time"
 
 	"ktkr.us/pkg/vfs/bindata"
@@ -41,131 +58,304 @@ import (
 
 func init() {
 `
-	dataFileSuffix = `}`
+	blobFileSuffix = `}`
 )
 
 func main() {
 	log.SetFlags(0)
 	log.SetPrefix("bindata: ")
 
-	flagSkip := flag.String("skip", "", "ListSeparator-delimited list of shell patterns matching file names to be skipped")
+	flagSkip := flag.String("skip", "", "deprecated alias for -exclude")
+	flagInclude := flag.String("include", "", "ListSeparator-delimited list of .gitignore-style patterns; when set, only matching paths are packed")
+	flagExclude := flag.String("exclude", "", "ListSeparator-delimited list of .gitignore-style patterns (supports **, trailing / for dirs, and leading ! to re-include) to skip")
+	flagCompress := flag.String("compress", "gzip", "blob compression codec to use in -mode=blob output: none, gzip, or zstd")
+	flagModTime := flag.String("modtime", "source", "modtime to record for every file: \"source\" (use each file's own mtime), \"zero\", or a unix timestamp")
+	flagMode := flag.String("mode", "blob", "output mode: \"blob\" (compressed blob + bindata.RegisterFile) or \"embed\" (//go:embed + bindata.RegisterFS)")
 	flag.Parse()
 	if flag.NArg() == 0 {
 		return
 	}
 
-	skipPatterns := filepath.SplitList(*flagSkip)
+	pkg, err := build.ImportDir(".", 0)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	os.MkdirAll(pkgName, 0755)
+	var excludePatterns []string
+	if *flagSkip != "" {
+		log.Print("-skip is deprecated, use -exclude instead")
+		excludePatterns = append(excludePatterns, filepath.SplitList(*flagSkip)...)
+	}
+	excludePatterns = append(excludePatterns, filepath.SplitList(*flagExclude)...)
+	filter := vfs.CompileFilter(vfs.FilterOpt{
+		IncludePatterns: filepath.SplitList(*flagInclude),
+		ExcludePatterns: excludePatterns,
+	})
 
-	for _, dir := range flag.Args() {
-		pc := []string{pkgName, dir + ".go"}
-		p := filepath.Join(pc...)
-		f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
-		if err != nil {
+	switch *flagMode {
+	case "blob":
+		if err := generateBlob(pkg, flag.Args(), filter, *flagCompress, *flagModTime); err != nil {
 			log.Fatal(err)
 		}
-		fmt.Fprintf(f, dataFilePrefix, pkgName)
-		err = filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
-			if fi.IsDir() || matchList(filepath.Base(p), skipPatterns) {
-				return nil
+	case "embed":
+		if err := generateEmbed(pkg, flag.Args()); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		log.Fatalf("unknown -mode %q", *flagMode)
+	}
+}
+
+// fileRecord is one file collected from an argument directory, in the
+// form the blob and embed generators both need.
+type fileRecord struct {
+	relPath string // slash-separated, relative to the argument directory
+	data    []byte
+	modTime time.Time
+	mode    os.FileMode
+}
+
+// collectFiles walks root and returns every file under it that filter
+// includes, sorted by relative path so output doesn't depend on
+// directory-entry iteration order.
+func collectFiles(root string, filter *vfs.Filterer, modTimeMode string) ([]fileRecord, error) {
+	var records []fileRecord
+
+	err := filepath.Walk(root, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == root {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if !filter.Included(rel, fi.IsDir()) {
+			if fi.IsDir() && !filter.ShouldDescend(rel) {
+				return filepath.SkipDir
 			}
-			return addFile(f, p)
-		})
+			return nil
+		}
+		if fi.IsDir() {
+			return nil
+		}
+
+		data, err := ioutil.ReadFile(p)
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
 
-		fmt.Fprintln(f, dataFileSuffix)
-		f.Close()
+		records = append(records, fileRecord{
+			relPath: rel,
+			data:    data,
+			modTime: resolveModTime(modTimeMode, fi),
+			mode:    fi.Mode(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// get the name of the current package
-	wd, err := os.Getwd()
-	if err != nil {
-		log.Fatal(err)
+	sort.Slice(records, func(i, j int) bool { return records[i].relPath < records[j].relPath })
+	return records, nil
+}
+
+func resolveModTime(mode string, fi os.FileInfo) time.Time {
+	switch mode {
+	case "", "source":
+		return fi.ModTime()
+	case "zero":
+		return time.Time{}
+	default:
+		if unix, err := strconv.ParseInt(mode, 10, 64); err == nil {
+			return time.Unix(unix, 0)
+		}
+		return fi.ModTime()
 	}
+}
 
-	pkg, err := build.ImportDir(wd, 0)
-	if err != nil {
-		log.Fatal(err)
+// generateBlob packs each argument directory into a single compressed
+// blob plus an entry table, in pkgName/<dir>.go, then writes fileName to
+// import pkgName for its init-time side effect, matching the layout the
+// tool has always used.
+func generateBlob(pkg *build.Package, dirs []string, filter *vfs.Filterer, compress, modTimeMode string) error {
+	if err := os.MkdirAll(pkgName, 0755); err != nil {
+		return err
 	}
 
-	f, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
-	if err != nil {
-		log.Fatal(err)
+	for _, dir := range dirs {
+		records, err := collectFiles(dir, filter, modTimeMode)
+		if err != nil {
+			return err
+		}
+
+		var raw bytes.Buffer
+		entries := make([]fileRecord, 0, len(records))
+		offsets := make([]int64, len(records))
+		for i, r := range records {
+			offsets[i] = int64(raw.Len())
+			raw.Write(r.data)
+			entries = append(entries, r)
+		}
+
+		blob, err := compressBlob(compress, raw.Bytes())
+		if err != nil {
+			return err
+		}
+
+		outPath := filepath.Join(pkgName, dir+".go")
+		f, err := os.OpenFile(outPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(f, blobFilePrefix, pkgName)
+		fmt.Fprintf(f, "\tbindata.RegisterFile(%s, %s, ", strconv.Quote(dir), strconv.Quote(compress))
+		writeBlobLiteral(f, blob)
+		fmt.Fprint(f, ", []bindata.Entry{\n")
+		for i, r := range entries {
+			fmt.Fprintf(f, "\t\t{Name: %s, Offset: %d, Length: %d, ModTime: time.Unix(%d, 0), Mode: %#o},\n",
+				strconv.Quote(r.relPath), offsets[i], len(r.data), r.modTime.Unix(), r.mode)
+		}
+		fmt.Fprint(f, "\t})\n")
+		fmt.Fprintln(f, blobFileSuffix)
+
+		if err := f.Close(); err != nil {
+			return err
+		}
 	}
 
-	fmt.Fprintf(f, importFile, pkg.Name, path.Join(pkg.ImportPath, pkgName))
-	f.Close()
+	return writeImportFile(pkg)
 }
 
-func addFile(w io.Writer, p string) error {
-	f, err := os.Open(p)
+// generateEmbed emits one //go:embed directive per argument directory
+// directly into the current package (go:embed paths are resolved relative
+// to the file that declares them), registering each with
+// bindata.RegisterFS at init time.
+func generateEmbed(pkg *build.Package, dirs []string) error {
+	f, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
 
-	fi, err := f.Stat()
-	if err != nil {
-		return err
+	fmt.Fprintf(f, "package %s\n\nimport (\n\t\"embed\"\n\t\"io/fs\"\n\n\t\"ktkr.us/pkg/vfs/bindata\"\n)\n\n", pkg.Name)
+
+	varNames := make([]string, len(dirs))
+	for i, dir := range dirs {
+		varNames[i] = embedVarName(dir)
+		fmt.Fprintf(f, "//go:embed %s\nvar %s embed.FS\n\n", dir, varNames[i])
 	}
 
-	pc := strings.Split(filepath.Clean(p), string([]rune{filepath.Separator}))
-	for i, s := range pc {
-		pc[i] = strconv.Quote(s)
+	fmt.Fprint(f, "func init() {\n")
+	for i, dir := range dirs {
+		// fs.Sub strips the directory's own name from the embedded
+		// paths, so Root(dir).Open("x") matches the blob mode's
+		// rooting instead of requiring "dir/x".
+		fmt.Fprintf(f, "\tsub%d, err := fs.Sub(%s, %s)\n\tif err != nil {\n\t\tpanic(err)\n\t}\n", i, varNames[i], strconv.Quote(dir))
+		fmt.Fprintf(f, "\tbindata.RegisterFS(%s, bindata.FromEmbed(sub%d))\n", strconv.Quote(dir), i)
 	}
-	joinExpr := fmt.Sprintf(`filepath.Join(%s)`, strings.Join(pc, ", "))
+	fmt.Fprint(f, "}\n")
 
-	fmt.Fprintf(w, "\tbindata.RegisterFile(%s, time.Unix(%d, 0), []byte(\"", joinExpr, fi.ModTime().Unix())
-	se := &stringEncoder{bufio.NewWriter(w)}
-	_, err = io.Copy(se, f)
-	fmt.Fprint(w, "\"))\n")
-	return err
+	return nil
 }
 
-type stringEncoder struct {
-	w *bufio.Writer
+func embedVarName(dir string) string {
+	parts := strings.FieldsFunc(dir, func(r rune) bool {
+		return !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9')
+	})
+	name := strings.Join(parts, "_")
+	if name == "" {
+		name = "bindataEmbed"
+	}
+	return name + "FS"
 }
 
-// Even if a utf-8 sequence is encountered and split down the middle on a
-// buffer boundary, the raw bytes will be written, no problem. It will just
-// look a little silly.
-func (se *stringEncoder) Write(p []byte) (int, error) {
-	for _, b := range p {
-		var err error
-		switch b {
-		case '\n':
-			_, err = se.w.WriteString(`\n`)
-		case '\\':
-			_, err = se.w.WriteString(`\\`)
-		case '"':
-			_, err = se.w.WriteString(`\"`)
-		default:
-			if 0x20 <= b && b < 0x7F {
-				err = se.w.WriteByte(b)
-			} else {
-				_, err = fmt.Fprintf(se.w, `\x%02x`, b)
-			}
-		}
+func writeImportFile(pkg *build.Package) error {
+	f, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fmt.Fprintf(f, importFile, pkg.Name, path.Join(pkg.ImportPath, pkgName))
+	return nil
+}
 
+func compressBlob(codec string, data []byte) ([]byte, error) {
+	switch codec {
+	case "", "none":
+		return data, nil
+	case "gzip":
+		var buf bytes.Buffer
+		zw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
 		if err != nil {
-			return 0, err
+			return nil, err
 		}
+		if _, err := zw.Write(data); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case "zstd":
+		var buf bytes.Buffer
+		zw, err := zstd.NewWriter(&buf, zstd.WithEncoderLevel(zstd.SpeedBestCompression))
+		if err != nil {
+			return nil, err
+		}
+		if _, err := zw.Write(data); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unknown -compress value %q", codec)
 	}
-
-	return len(p), se.w.Flush()
 }
 
-func matchList(name string, patterns []string) bool {
-	if len(patterns) == 0 {
-		return false
-	}
-	for _, pat := range patterns {
-		if m, _ := filepath.Match(pat, name); m {
-			return true
+// blobLiteralLineLen keeps each emitted string literal well under gofmt's
+// usual line-length comfort zone even after the surrounding quotes and
+// indentation, so generated files stay readable in a diff.
+const blobLiteralLineLen = 96
+
+// writeBlobLiteral emits data as a bindata.MustDecodeBlob call over a
+// base64-encoded string literal, split across lines and joined with "+".
+// Base64 costs about 4/3 source chars per input byte -- far denser than
+// either a "0x%02x," composite literal (6 chars/byte) or a \xNN-escaped
+// string (4 chars/byte for the typical non-printable compressed blob, and
+// worse yet it risks the Go scanner tokenizing unlucky byte sequences as
+// multi-byte escapes).
+func writeBlobLiteral(w io.Writer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	bw := bufio.NewWriter(w)
+	bw.WriteString("bindata.MustDecodeBlob(")
+	indent := "\n\t\t"
+	for i := 0; i < len(encoded); i += blobLiteralLineLen {
+		end := i + blobLiteralLineLen
+		if end > len(encoded) {
+			end = len(encoded)
+		}
+		fmt.Fprintf(bw, "%s%q", indent, encoded[i:end])
+		if end < len(encoded) {
+			// Go's automatic semicolon insertion would otherwise end the
+			// statement right after the string literal that precedes a
+			// newline, regardless of the open paren around it, so the "+"
+			// has to stay on the line it continues rather than leading
+			// the next one. gofmt deepens the indent of every continuation
+			// line after the first, so match that here too.
+			bw.WriteString("+")
+			indent = "\n\t\t\t"
 		}
 	}
-	return false
+	bw.WriteString(")")
+	bw.Flush()
 }
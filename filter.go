@@ -0,0 +1,246 @@
+package vfs
+
+import (
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// FilterOpt configures Filter and CompileFilter. Patterns follow
+// .gitignore/.dockerignore conventions: a pattern containing no "/"
+// matches a path component at any depth (e.g. "node_modules" hides every
+// directory or file named that, however deep); a pattern containing "/"
+// is anchored to the filtered root; "**" matches zero or more path
+// components; a trailing "/" restricts the pattern to directories; and a
+// leading "!" re-includes a path an earlier pattern excluded.
+//
+// ExcludePatterns are evaluated in order, each later match overriding any
+// earlier one, exactly like the lines of a .gitignore file. IncludePatterns
+// are a separate allowlist: when non-empty, a path must match at least one
+// of them to be considered at all, before ExcludePatterns are evaluated.
+type FilterOpt struct {
+	IncludePatterns []string
+	ExcludePatterns []string
+
+	// FollowPaths lists paths (or path prefixes) that Walk should keep
+	// recursing into even though they match an ExcludePatterns entry,
+	// because a later pattern re-includes something nested inside them.
+	// Without an entry here, Walk short-circuits recursion into an
+	// excluded directory outright, which is usually what you want on a
+	// large tree but would also hide a nested "!sub/foo" re-include.
+	FollowPaths []string
+}
+
+// Filter returns a FileSystem that hides paths under fsys matching opt,
+// during both Open and Walk.
+func Filter(fsys FileSystem, opt FilterOpt) FileSystem {
+	return &filterFS{fs: fsys, matcher: newMatcher(opt)}
+}
+
+type filterFS struct {
+	fs      FileSystem
+	matcher *matcher
+}
+
+func relOf(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(name)), "/")
+}
+
+func (f *filterFS) Open(name string) (http.File, error) {
+	rel := relOf(name)
+	if rel == "." || rel == "" {
+		return f.fs.Open(name)
+	}
+
+	hf, err := f.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := hf.Stat()
+	if err != nil {
+		hf.Close()
+		return nil, err
+	}
+	if f.matcher.excluded(rel, info.IsDir()) {
+		hf.Close()
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	return hf, nil
+}
+
+func (f *filterFS) Walk(root string, fn filepath.WalkFunc) error {
+	return f.fs.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return fn(p, info, err)
+		}
+
+		rel := relOf(p)
+		if rel == "." || rel == "" {
+			return fn(p, info, err)
+		}
+
+		if f.matcher.excluded(rel, info.IsDir()) {
+			if info.IsDir() {
+				if f.matcher.shouldFollow(rel) {
+					return nil
+				}
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		return fn(p, info, err)
+	})
+}
+
+// CompileFilter compiles opt into a Filterer that callers can consult
+// per path during their own os.FileInfo-based walk (e.g. the bindata
+// tool's), without needing to wrap a FileSystem.
+func CompileFilter(opt FilterOpt) *Filterer {
+	return &Filterer{m: newMatcher(opt)}
+}
+
+// Filterer is the compiled form of a FilterOpt.
+type Filterer struct {
+	m *matcher
+}
+
+// Included reports whether relPath (slash-separated, relative to the
+// filtered root) should be kept.
+func (f *Filterer) Included(relPath string, isDir bool) bool {
+	return !f.m.excluded(relPath, isDir)
+}
+
+// ShouldDescend reports whether a walk should still recurse into the
+// excluded directory relPath, per FollowPaths.
+func (f *Filterer) ShouldDescend(relPath string) bool {
+	return f.m.shouldFollow(relPath)
+}
+
+// matcher is the compiled, ordered form of a FilterOpt.
+type matcher struct {
+	include []pattern
+	exclude []pattern
+	follow  []string
+}
+
+func newMatcher(opt FilterOpt) *matcher {
+	m := &matcher{follow: opt.FollowPaths}
+	for _, s := range opt.IncludePatterns {
+		m.include = append(m.include, compilePattern(s))
+	}
+	for _, s := range opt.ExcludePatterns {
+		m.exclude = append(m.exclude, compilePattern(s))
+	}
+	return m
+}
+
+// excluded reports whether relPath (slash-separated, no leading slash)
+// should be hidden, per the rules documented on FilterOpt.
+func (m *matcher) excluded(relPath string, isDir bool) bool {
+	components := strings.Split(relPath, "/")
+
+	if len(m.include) > 0 {
+		included := false
+		for _, p := range m.include {
+			if p.matches(components, isDir) {
+				included = true
+			}
+		}
+		if !included {
+			return true
+		}
+	}
+
+	excluded := false
+	for _, p := range m.exclude {
+		if p.matches(components, isDir) {
+			excluded = !p.negate
+		}
+	}
+	return excluded
+}
+
+func (m *matcher) shouldFollow(relPath string) bool {
+	for _, f := range m.follow {
+		if f == relPath || strings.HasPrefix(f, relPath+"/") || strings.HasPrefix(relPath, f+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// pattern is one compiled line of a FilterOpt's Include/ExcludePatterns.
+type pattern struct {
+	negate   bool
+	dirOnly  bool
+	anchored bool // contains a "/", so matched against the full path rather than just the base name
+	segments []string
+}
+
+func compilePattern(raw string) pattern {
+	var p pattern
+
+	s := raw
+	if strings.HasPrefix(s, "!") {
+		p.negate = true
+		s = s[1:]
+	}
+	if strings.HasSuffix(s, "/") {
+		p.dirOnly = true
+		s = strings.TrimSuffix(s, "/")
+	}
+	p.anchored = strings.Contains(s, "/")
+	s = strings.TrimPrefix(s, "/")
+	p.segments = strings.Split(s, "/")
+	return p
+}
+
+func (p pattern) matches(components []string, isDir bool) bool {
+	if !p.anchored {
+		// An unanchored pattern matches a path component at any depth,
+		// not just the last one: excluding "node_modules" must exclude
+		// "node_modules/foo.js" too, the same way a .gitignore line
+		// would. Every component before the last is necessarily a
+		// directory, so dirOnly only rules out a match on the last one.
+		for i, c := range components {
+			if p.dirOnly && i == len(components)-1 && !isDir {
+				continue
+			}
+			if ok, _ := path.Match(p.segments[0], c); ok {
+				return true
+			}
+		}
+		return false
+	}
+	if p.dirOnly && !isDir {
+		return false
+	}
+	return matchSegments(p.segments, components)
+}
+
+// matchSegments matches a "**"-aware glob pattern against path components,
+// same semantics as .gitignore.
+func matchSegments(pat, comp []string) bool {
+	if len(pat) == 0 {
+		return len(comp) == 0
+	}
+	if pat[0] == "**" {
+		if matchSegments(pat[1:], comp) {
+			return true
+		}
+		if len(comp) == 0 {
+			return false
+		}
+		return matchSegments(pat, comp[1:])
+	}
+	if len(comp) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pat[0], comp[0]); !ok {
+		return false
+	}
+	return matchSegments(pat[1:], comp[1:])
+}
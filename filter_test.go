@@ -0,0 +1,30 @@
+package vfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFilterExcludesDescendantsOfUnanchoredPattern(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "node_modules"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "node_modules", "secret.js"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	native, err := Native(dir, ResolveOpt{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	filtered := Filter(native, FilterOpt{ExcludePatterns: []string{"node_modules"}})
+
+	if _, err := filtered.Open("node_modules"); !os.IsNotExist(err) {
+		t.Errorf("Open(%q) = %v, want IsNotExist", "node_modules", err)
+	}
+	if _, err := filtered.Open("node_modules/secret.js"); !os.IsNotExist(err) {
+		t.Errorf("Open(%q) = %v, want IsNotExist", "node_modules/secret.js", err)
+	}
+}
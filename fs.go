@@ -0,0 +1,201 @@
+package vfs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+var errIsFile = errors.New("is a file")
+
+// AsFS adapts fsys to the standard library's io/fs.FS interface. The
+// returned value also implements fs.ReadDirFS, fs.ReadFileFS, fs.StatFS,
+// fs.GlobFS, and fs.SubFS, so it can be passed directly to
+// text/template.ParseFS, html/template.ParseFS, http.FS, and similar
+// io/fs-based APIs.
+//
+// Files returned by the adapter keep their concrete type from fsys, so
+// backends that expose extra capabilities (such as bindata's ReadDir
+// without full os.FileInfo materialization) keep them after wrapping.
+func AsFS(fsys FileSystem) fs.FS {
+	return &fsAdapter{fsys}
+}
+
+type fsAdapter struct {
+	fs FileSystem
+}
+
+func (a *fsAdapter) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	return a.fs.Open(name)
+}
+
+// readDirFile is satisfied by file handles that can list their own
+// children as fs.DirEntry without going through Readdir's os.FileInfo.
+type readDirFile interface {
+	ReadDir(n int) ([]fs.DirEntry, error)
+}
+
+// open is the validated entry point ReadDir, ReadFile, and Stat use
+// instead of calling a.fs.Open directly, so every fsAdapter method --
+// not just Open -- rejects a name that isn't fs.ValidPath, the same
+// requirement every other io/fs.FS implementation is held to.
+func (a *fsAdapter) open(name string) (http.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	return a.fs.Open(name)
+}
+
+func (a *fsAdapter) ReadDir(name string) ([]fs.DirEntry, error) {
+	f, err := a.open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []fs.DirEntry
+	if rd, ok := f.(readDirFile); ok {
+		entries, err = rd.ReadDir(-1)
+	} else {
+		var infos []os.FileInfo
+		infos, err = f.Readdir(-1)
+		entries = make([]fs.DirEntry, len(infos))
+		for i, fi := range infos {
+			entries[i] = fs.FileInfoToDirEntry(fi)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func (a *fsAdapter) ReadFile(name string) ([]byte, error) {
+	f, err := a.open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+func (a *fsAdapter) Stat(name string) (fs.FileInfo, error) {
+	f, err := a.open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.Stat()
+}
+
+func (a *fsAdapter) Glob(pattern string) ([]string, error) {
+	// Wrap in a plain fs.FS so fs.Glob doesn't recurse back into this
+	// method through the GlobFS fast path.
+	return fs.Glob(struct{ fs.FS }{a}, pattern)
+}
+
+func (a *fsAdapter) Sub(dir string) (fs.FS, error) {
+	return AsFS(Subdir(a.fs, dir)), nil
+}
+
+// FromFS adapts fsys to the vfs.FileSystem interface, so an io/fs.FS
+// (including embed.FS or testing/fstest.MapFS) can be used anywhere a
+// FileSystem is expected.
+func FromFS(fsys fs.FS) FileSystem {
+	return &fsysFS{fsys}
+}
+
+type fsysFS struct {
+	fsys fs.FS
+}
+
+func (w *fsysFS) Open(name string) (http.File, error) {
+	rel, err := resolvePath(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	f, err := w.fsys.Open(rel)
+	if err != nil {
+		return nil, err
+	}
+	return &fsFile{File: f}, nil
+}
+
+func (w *fsysFS) Walk(root string, f filepath.WalkFunc) error {
+	rel, err := resolvePath(root)
+	if err != nil {
+		return &fs.PathError{Op: "walk", Path: root, Err: err}
+	}
+	return fs.WalkDir(w.fsys, rel, func(p string, d fs.DirEntry, err error) error {
+		var info os.FileInfo
+		if err == nil {
+			info, err = d.Info()
+		}
+		return f(p, info, err)
+	})
+}
+
+// fsFile adapts an fs.File to http.File. If the underlying file doesn't
+// implement io.Seeker, it is read into memory on first Seek, mirroring
+// how net/http.FS handles non-seekable io/fs.FS implementations.
+type fsFile struct {
+	fs.File
+	seeker io.ReadSeeker
+}
+
+func (f *fsFile) Read(p []byte) (int, error) {
+	if f.seeker != nil {
+		return f.seeker.Read(p)
+	}
+	return f.File.Read(p)
+}
+
+func (f *fsFile) Seek(offset int64, whence int) (int64, error) {
+	if s, ok := f.File.(io.Seeker); ok {
+		return s.Seek(offset, whence)
+	}
+	if f.seeker == nil {
+		b, err := io.ReadAll(f.File)
+		if err != nil {
+			return 0, err
+		}
+		f.seeker = bytes.NewReader(b)
+	}
+	return f.seeker.Seek(offset, whence)
+}
+
+func (f *fsFile) Readdir(count int) ([]os.FileInfo, error) {
+	rdf, ok := f.File.(fs.ReadDirFile)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: "", Err: errIsFile}
+	}
+	entries, err := rdf.ReadDir(count)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]os.FileInfo, len(entries))
+	for i, e := range entries {
+		fi, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		infos[i] = fi
+	}
+	return infos, nil
+}
+
+// WalkDir walks the file tree rooted at root in fsys, calling fn for each
+// file or directory, using fs.WalkDir/fs.DirEntry semantics instead of
+// filepath.WalkFunc/os.FileInfo.
+func WalkDir(fsys FileSystem, root string, fn fs.WalkDirFunc) error {
+	return fs.WalkDir(AsFS(fsys), root, fn)
+}
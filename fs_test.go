@@ -0,0 +1,69 @@
+package vfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+// writeTestTree lays out the same small fixture -- a top-level file and a
+// file in a subdirectory -- under dir, for tests that exercise an
+// io/fs.FS adapter with fstest.TestFS.
+func writeTestTree(t *testing.T, dir string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "b.txt"), []byte("b"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAsFSNativeConformsToFstest(t *testing.T) {
+	dir := t.TempDir()
+	writeTestTree(t, dir)
+
+	native, err := Native(dir, ResolveOpt{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(AsFS(native), "a.txt", "sub/b.txt"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAsFSMemFSConformsToFstest(t *testing.T) {
+	mem := NewMemFS()
+
+	if f, err := mem.OpenFile("a.txt", os.O_CREATE|os.O_WRONLY, 0644); err != nil {
+		t.Fatal(err)
+	} else {
+		if _, err := f.(interface {
+			Write([]byte) (int, error)
+		}).Write([]byte("a")); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+	}
+	if err := mem.Mkdir("sub", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if f, err := mem.OpenFile("sub/b.txt", os.O_CREATE|os.O_WRONLY, 0644); err != nil {
+		t.Fatal(err)
+	} else {
+		if _, err := f.(interface {
+			Write([]byte) (int, error)
+		}).Write([]byte("b")); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+	}
+
+	if err := fstest.TestFS(AsFS(mem), "a.txt", "sub/b.txt"); err != nil {
+		t.Fatal(err)
+	}
+}
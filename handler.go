@@ -0,0 +1,150 @@
+package vfs
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+// precompressedVariants is tried in preference order: brotli first, then
+// gzip, matching how most CDNs and reverse proxies default their own
+// Accept-Encoding preference.
+var precompressedVariants = []struct {
+	encoding string
+	suffix   string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// Handler returns an http.Handler serving files out of fsys with correct
+// ETag, If-None-Match, and Last-Modified handling. If fsys implements
+// Hasher, its digest is used for the ETag; otherwise no ETag is sent and
+// only Last-Modified/If-Modified-Since apply. If a sibling "name.gz" or
+// "name.br" exists in fsys and the request's Accept-Encoding allows it,
+// it is served instead with the matching Content-Encoding and a Vary
+// header, while Content-Type is still derived from name.
+//
+// Handler does not serve directory listings; a request for a directory
+// gets a 404.
+func Handler(fsys FileSystem) http.Handler {
+	return &fsHandler{fs: fsys}
+}
+
+type fsHandler struct {
+	fs FileSystem
+}
+
+func (h *fsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := path.Clean(r.URL.Path)
+
+	f, info, encoding, err := h.openBest(name, r.Header.Get("Accept-Encoding"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+
+	if info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	if etag := h.etag(name, encoding); etag != "" {
+		w.Header().Set("ETag", etag)
+		if inm := r.Header.Get("If-None-Match"); inm != "" && etagMatches(inm, etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	if encoding != "" {
+		w.Header().Set("Content-Encoding", encoding)
+		w.Header().Set("Vary", "Accept-Encoding")
+	}
+
+	rs, ok := f.(io.ReadSeeker)
+	if !ok {
+		http.Error(w, "file does not support seeking", http.StatusInternalServerError)
+		return
+	}
+	http.ServeContent(w, r, name, info.ModTime(), rs)
+}
+
+// openBest opens the most preferred pre-compressed variant of name that
+// both exists in fsys and is acceptable per acceptEncoding, falling back
+// to name itself. It returns the encoding used ("" for the uncompressed
+// fallback).
+func (h *fsHandler) openBest(name, acceptEncoding string) (http.File, os.FileInfo, string, error) {
+	for _, v := range precompressedVariants {
+		if !acceptEncodingAllows(acceptEncoding, v.encoding) {
+			continue
+		}
+		f, info, err := openStat(h.fs, name+v.suffix)
+		if err == nil {
+			return f, info, v.encoding, nil
+		}
+	}
+
+	f, info, err := openStat(h.fs, name)
+	return f, info, "", err
+}
+
+func openStat(fsys FileSystem, name string) (http.File, os.FileInfo, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return f, info, nil
+}
+
+func (h *fsHandler) etag(name, encoding string) string {
+	hasher, ok := h.fs.(Hasher)
+	if !ok {
+		return ""
+	}
+
+	target := name
+	for _, v := range precompressedVariants {
+		if v.encoding == encoding {
+			target += v.suffix
+			break
+		}
+	}
+
+	alg, sum, ok := hasher.Hash(target)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf(`"%s-%x"`, alg, sum)
+}
+
+func acceptEncodingAllows(header, encoding string) bool {
+	for _, part := range strings.Split(header, ",") {
+		name, _, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if name == encoding {
+			return true
+		}
+	}
+	return false
+}
+
+func etagMatches(ifNoneMatch, etag string) bool {
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+	for _, part := range strings.Split(ifNoneMatch, ",") {
+		if strings.TrimSpace(part) == etag {
+			return true
+		}
+	}
+	return false
+}
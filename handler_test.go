@@ -0,0 +1,168 @@
+package vfs
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+)
+
+func newMemFSHandler(t *testing.T, files map[string]string) (WritableFileSystem, http.Handler) {
+	t.Helper()
+	w := NewMemFS()
+	for name, data := range files {
+		if dir := path.Dir(name); dir != "." {
+			if err := w.MkdirAll(dir, 0755); err != nil {
+				t.Fatal(err)
+			}
+		}
+		f, err := w.OpenFile(name, os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.(io.Writer).Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+	}
+	return w, Handler(w)
+}
+
+func TestHandlerServesFile(t *testing.T) {
+	_, h := newMemFSHandler(t, map[string]string{"a.txt": "hello"})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/a.txt", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	if rec.Body.String() != "hello" {
+		t.Errorf("body = %q, want %q", rec.Body.String(), "hello")
+	}
+}
+
+func TestHandlerMissingFileIs404(t *testing.T) {
+	_, h := newMemFSHandler(t, nil)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/missing.txt", nil))
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", rec.Code)
+	}
+}
+
+func TestHandlerDirectoryIs404(t *testing.T) {
+	_, h := newMemFSHandler(t, map[string]string{"sub/a.txt": "x"})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/sub", nil))
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", rec.Code)
+	}
+}
+
+// ETags only come from a backend implementing Hasher, which memFS does
+// not -- use a native-backed handler here instead.
+func TestHandlerETagAndIfNoneMatch(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fsys, err := Native(dir, ResolveOpt{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := Handler(fsys)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/a.txt", nil))
+	etag := rec.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("no ETag set, want one from the Hasher-backed nativeFS")
+	}
+
+	req := httptest.NewRequest("GET", "/a.txt", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec2 := httptest.NewRecorder()
+	h.ServeHTTP(rec2, req)
+
+	if rec2.Code != http.StatusNotModified {
+		t.Errorf("status with matching If-None-Match = %d, want 304", rec2.Code)
+	}
+
+	req3 := httptest.NewRequest("GET", "/a.txt", nil)
+	req3.Header.Set("If-None-Match", `"sha256-deadbeef"`)
+	rec3 := httptest.NewRecorder()
+	h.ServeHTTP(rec3, req3)
+	if rec3.Code != http.StatusOK {
+		t.Errorf("status with stale If-None-Match = %d, want 200", rec3.Code)
+	}
+}
+
+func TestHandlerPrecompressedVariantSelection(t *testing.T) {
+	_, h := newMemFSHandler(t, map[string]string{
+		"a.txt":    "uncompressed",
+		"a.txt.gz": "gzipped-bytes",
+	})
+
+	req := httptest.NewRequest("GET", "/a.txt", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	if got := rec.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Errorf("Content-Encoding = %q, want gzip", got)
+	}
+	if got := rec.Header().Get("Vary"); got != "Accept-Encoding" {
+		t.Errorf("Vary = %q, want Accept-Encoding", got)
+	}
+	if rec.Body.String() != "gzipped-bytes" {
+		t.Errorf("body = %q, want the precompressed variant's bytes", rec.Body.String())
+	}
+}
+
+func TestHandlerPrecompressedVariantSkippedWithoutAcceptEncoding(t *testing.T) {
+	_, h := newMemFSHandler(t, map[string]string{
+		"a.txt":    "uncompressed",
+		"a.txt.gz": "gzipped-bytes",
+	})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/a.txt", nil))
+
+	if rec.Header().Get("Content-Encoding") != "" {
+		t.Errorf("Content-Encoding = %q, want none when client sent no Accept-Encoding", rec.Header().Get("Content-Encoding"))
+	}
+	if rec.Body.String() != "uncompressed" {
+		t.Errorf("body = %q, want the uncompressed fallback's bytes", rec.Body.String())
+	}
+}
+
+func TestHandlerPrecompressedVariantPrefersBrotliOverGzip(t *testing.T) {
+	_, h := newMemFSHandler(t, map[string]string{
+		"a.txt":    "uncompressed",
+		"a.txt.gz": "gzip-bytes",
+		"a.txt.br": "brotli-bytes",
+	})
+
+	req := httptest.NewRequest("GET", "/a.txt", nil)
+	req.Header.Set("Accept-Encoding", "gzip, br")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Content-Encoding"); got != "br" {
+		t.Errorf("Content-Encoding = %q, want br (preferred over gzip)", got)
+	}
+	if rec.Body.String() != "brotli-bytes" {
+		t.Errorf("body = %q, want the brotli variant's bytes", rec.Body.String())
+	}
+}
@@ -0,0 +1,115 @@
+package vfs
+
+import (
+	"crypto/sha256"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Hasher is implemented by backends that can report a content hash for a
+// file without the caller reading and hashing it itself: either because
+// the backend already knows it (bindata's files are immutable once
+// registered), or because it keeps its own cache (nativeFS). Handler uses
+// it to generate ETags.
+type Hasher interface {
+	// Hash returns the digest of name's contents as an algorithm name
+	// (e.g. "sha256") and raw sum bytes. ok is false if name doesn't
+	// exist, is a directory, or no hash is available.
+	Hash(name string) (alg string, sum []byte, ok bool)
+}
+
+// hashCache is a small bounded LRU of on-demand sha256 digests, keyed by
+// root-relative path and invalidated by comparing the cached mtime/size against
+// the file's current stat. nativeFS uses one to avoid rehashing unchanged
+// files on every request.
+type hashCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    []string
+	entries  map[string]hashCacheEntry
+}
+
+type hashCacheEntry struct {
+	modTime time.Time
+	size    int64
+	sum     [sha256.Size]byte
+}
+
+func newHashCache(capacity int) *hashCache {
+	return &hashCache{capacity: capacity, entries: map[string]hashCacheEntry{}}
+}
+
+func (c *hashCache) get(path string, modTime time.Time, size int64) ([sha256.Size]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[path]
+	if !ok || !e.modTime.Equal(modTime) || e.size != size {
+		return [sha256.Size]byte{}, false
+	}
+	c.touch(path)
+	return e.sum, true
+}
+
+func (c *hashCache) put(path string, modTime time.Time, size int64, sum [sha256.Size]byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.entries[path]; !exists {
+		if len(c.order) >= c.capacity {
+			var oldest string
+			oldest, c.order = c.order[0], c.order[1:]
+			delete(c.entries, oldest)
+		}
+		c.order = append(c.order, path)
+	} else {
+		c.touch(path)
+	}
+	c.entries[path] = hashCacheEntry{modTime: modTime, size: size, sum: sum}
+}
+
+// touch moves path to the most-recently-used end of order. Callers must
+// hold c.mu and path must already be present in c.order.
+func (c *hashCache) touch(path string) {
+	for i, p := range c.order {
+		if p == path {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			break
+		}
+	}
+	c.order = append(c.order, path)
+}
+
+// Hash implements Hasher for nativeFS by hashing the file on first request
+// and caching the result until its mtime or size changes. It goes through
+// openRel, the same containment Open uses, so a symlink under root can't
+// be used to read outside of it just by asking for its hash.
+func (fs *nativeFS) Hash(name string) (alg string, sum []byte, ok bool) {
+	rel, err := resolvePath(name)
+	if err != nil {
+		return "", nil, false
+	}
+
+	f, err := fs.openRel(rel, os.O_RDONLY, 0)
+	if err != nil {
+		return "", nil, false
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		return "", nil, false
+	}
+
+	if cached, ok := fs.hashes.get(rel, info.ModTime(), info.Size()); ok {
+		return "sha256", cached[:], true
+	}
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return "", nil, false
+	}
+	digest := sha256.Sum256(data)
+	fs.hashes.put(rel, info.ModTime(), info.Size(), digest)
+	return "sha256", digest[:], true
+}
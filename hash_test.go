@@ -0,0 +1,125 @@
+package vfs
+
+import (
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHashCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newHashCache(2)
+	t0 := time.Unix(0, 0)
+	sumA := sha256.Sum256([]byte("a"))
+	sumB := sha256.Sum256([]byte("b"))
+	sumC := sha256.Sum256([]byte("c"))
+
+	c.put("a", t0, 1, sumA)
+	c.put("b", t0, 1, sumB)
+
+	// Touch "a" via a read hit so "b" becomes the least recently used
+	// entry, not "a". A non-LRU cache that only tracks insertion order
+	// would evict "a" below instead.
+	if _, ok := c.get("a", t0, 1); !ok {
+		t.Fatal("get(a) miss, want hit")
+	}
+
+	c.put("c", t0, 1, sumC)
+
+	if _, ok := c.get("b", t0, 1); ok {
+		t.Error("get(b) hit after eviction, want miss: LRU evicted the wrong entry")
+	}
+	if _, ok := c.get("a", t0, 1); !ok {
+		t.Error("get(a) miss, want hit: recently touched entry should have survived eviction")
+	}
+	if _, ok := c.get("c", t0, 1); !ok {
+		t.Error("get(c) miss, want hit")
+	}
+}
+
+func TestHashCacheInvalidatesOnModTimeOrSizeChange(t *testing.T) {
+	c := newHashCache(4)
+	t0 := time.Unix(0, 0)
+	t1 := time.Unix(1, 0)
+	sum := sha256.Sum256([]byte("x"))
+
+	c.put("f", t0, 1, sum)
+
+	if _, ok := c.get("f", t1, 1); ok {
+		t.Error("get with changed modTime hit, want miss")
+	}
+	if _, ok := c.get("f", t0, 2); ok {
+		t.Error("get with changed size hit, want miss")
+	}
+	if _, ok := c.get("f", t0, 1); !ok {
+		t.Error("get with unchanged modTime/size missed, want hit")
+	}
+}
+
+func TestNativeFSHashCachesUntilModified(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "f.txt")
+	if err := os.WriteFile(p, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fsys, err := Native(dir, ResolveOpt{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hasher, ok := fsys.(Hasher)
+	if !ok {
+		t.Fatal("Native-backed FileSystem does not implement Hasher")
+	}
+
+	alg, sum1, ok := hasher.Hash("f.txt")
+	if !ok {
+		t.Fatal("Hash(f.txt) returned ok=false")
+	}
+	if alg != "sha256" {
+		t.Errorf("alg = %q, want sha256", alg)
+	}
+
+	_, sum2, ok := hasher.Hash("f.txt")
+	if !ok || string(sum2) != string(sum1) {
+		t.Errorf("second Hash(f.txt) = %x, ok=%v; want %x, ok=true", sum2, ok, sum1)
+	}
+
+	// Give the filesystem's mtime resolution room to register a change.
+	future := time.Now().Add(2 * time.Second)
+	if err := os.WriteFile(p, []byte("goodbye"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(p, future, future); err != nil {
+		t.Fatal(err)
+	}
+
+	_, sum3, ok := hasher.Hash("f.txt")
+	if !ok {
+		t.Fatal("Hash(f.txt) after modification returned ok=false")
+	}
+	if string(sum3) == string(sum1) {
+		t.Error("Hash(f.txt) after modification returned the stale cached digest")
+	}
+}
+
+func TestNativeFSHashMissingOrDirectory(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	fsys, err := Native(dir, ResolveOpt{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hasher := fsys.(Hasher)
+
+	if _, _, ok := hasher.Hash("missing"); ok {
+		t.Error("Hash(missing) returned ok=true, want false")
+	}
+	if _, _, ok := hasher.Hash("sub"); ok {
+		t.Error("Hash(sub) for a directory returned ok=true, want false")
+	}
+}
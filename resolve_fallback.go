@@ -0,0 +1,44 @@
+package vfs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// checkBeneath walks rel one component at a time from root, Lstat-ing
+// each one so a symlink planted anywhere along the path -- not just at
+// the leaf -- can be refused (unless followSymlinks) instead of silently
+// followed out of root. A missing intermediate component is not an
+// error: callers that create paths (Mkdir, MkdirAll, OpenFile with
+// O_CREATE, Rename's destination) expect to name something that doesn't
+// exist yet. It returns the real, root-joined path.
+func checkBeneath(root, rel string, followSymlinks bool) (string, error) {
+	dir := root
+	for _, part := range strings.Split(rel, "/") {
+		if part == "." {
+			continue
+		}
+		next := filepath.Join(dir, part)
+		if !followSymlinks {
+			if info, err := os.Lstat(next); err == nil && info.Mode()&os.ModeSymlink != 0 {
+				return "", &os.PathError{Op: "open", Path: rel, Err: os.ErrPermission}
+			}
+		}
+		dir = next
+	}
+	return dir, nil
+}
+
+// openBeneathFallback is openBeneath's portable implementation, used
+// directly on platforms without openat2 and as the fallback when the
+// running Linux kernel doesn't support it either. It can't close the
+// check-then-open race the way RESOLVE_BENEATH does atomically, but it
+// works on any OS or kernel.
+func openBeneathFallback(root, rel string, flag int, perm os.FileMode, followSymlinks bool) (*os.File, error) {
+	p, err := checkBeneath(root, rel, followSymlinks)
+	if err != nil {
+		return nil, err
+	}
+	return os.OpenFile(p, flag, perm)
+}
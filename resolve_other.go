@@ -0,0 +1,12 @@
+//go:build !linux
+
+package vfs
+
+import "os"
+
+// openBeneath falls back to a portable per-component Lstat-then-Open
+// loop on platforms without openat2; see resolve_linux.go for the Linux
+// path that additionally tries the kernel-enforced RESOLVE_BENEATH route.
+func openBeneath(root, rel string, flag int, perm os.FileMode, opt ResolveOpt) (*os.File, error) {
+	return openBeneathFallback(root, rel, flag, perm, opt.FollowSymlinks)
+}
@@ -0,0 +1,105 @@
+package vfs
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestBeneathRootRejectsDotDotEscape(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	if err := os.Mkdir(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "secret"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fsys, err := Native(root, ResolveOpt{BeneathRoot: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fsys.Open("../secret"); err == nil {
+		t.Error("Open(\"../secret\") succeeded, want error")
+	}
+
+	var walked []string
+	err = fsys.Walk("../secret", func(p string, info os.FileInfo, err error) error {
+		walked = append(walked, p)
+		return err
+	})
+	if err == nil {
+		t.Errorf("Walk(\"../secret\") succeeded and visited %v, want error", walked)
+	}
+}
+
+func TestBeneathRootRejectsSymlinkEscape(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlink creation requires elevated privileges on windows")
+	}
+
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	if err := os.Mkdir(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "secret"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "secret"), filepath.Join(root, "escape")); err != nil {
+		t.Skipf("could not create symlink: %v", err)
+	}
+
+	fsys, err := Native(root, ResolveOpt{BeneathRoot: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fsys.Open("escape"); err == nil {
+		t.Error("Open(\"escape\") followed a symlink out of root, want error")
+	}
+
+	w, ok := fsys.(WritableFileSystem)
+	if !ok {
+		t.Fatal("Native-backed FileSystem does not implement WritableFileSystem")
+	}
+	if err := w.RemoveAll("escape"); err == nil {
+		t.Error("RemoveAll(\"escape\") followed a symlink out of root, want error")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "secret")); err != nil {
+		t.Fatalf("RemoveAll(\"escape\") removed the symlink target outside root: %v", err)
+	}
+	if _, err := w.OpenFile("escape", os.O_RDWR, 0); err == nil {
+		t.Error("OpenFile(\"escape\") followed a symlink out of root, want error")
+	}
+}
+
+func TestDefaultResolveOptFollowsSymlinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlink creation requires elevated privileges on windows")
+	}
+
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	if err := os.Mkdir(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "secret"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "secret"), filepath.Join(root, "escape")); err != nil {
+		t.Skipf("could not create symlink: %v", err)
+	}
+
+	fsys, err := Native(root, ResolveOpt{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := fsys.Open("escape")
+	if err != nil {
+		t.Fatalf("Open(\"escape\") with the permissive default failed: %v", err)
+	}
+	f.Close()
+}
@@ -20,15 +20,22 @@ type FileSystem interface {
 // nativeFS is an implementation of FileSystem that wraps the OS filesystem
 type nativeFS struct {
 	http.Dir
+	hashes *hashCache
+	opt    ResolveOpt
 }
 
-// Native returns a disk-backed FileSystem rooted at root. It returns an error
-// if root does not exist.
-func Native(root string) (FileSystem, error) {
+// Native returns a disk-backed FileSystem rooted at root. It returns an
+// error if root does not exist. opt controls how strictly the returned
+// FileSystem is contained to root; pass ResolveOpt{} for the historical
+// behavior of following symlinks wherever they point, or
+// ResolveOpt{BeneathRoot: true} to refuse to open anything that resolves
+// outside root, which is what you want when root is a user-controlled
+// directory. See ResolveOpt and resolvePath.
+func Native(root string, opt ResolveOpt) (FileSystem, error) {
 	if _, err := os.Stat(root); err != nil {
 		return nil, err
 	}
-	return &nativeFS{http.Dir(root)}, nil
+	return &nativeFS{Dir: http.Dir(root), hashes: newHashCache(1024), opt: opt}, nil
 }
 
 func stripPrefixWalkFunc(f filepath.WalkFunc, prefix string) filepath.WalkFunc {
@@ -45,15 +52,36 @@ func stripPrefixWalkFunc(f filepath.WalkFunc, prefix string) filepath.WalkFunc {
 	}
 }
 
+// Walk resolves root via resolvePath the same way Open does, rejecting
+// any ".." component before it ever reaches a real filesystem path --
+// filepath.Join alone would silently collapse a ".." in root onto the
+// real disk path, walking straight past the vfs root. When
+// fs.opt.BeneathRoot is set, it additionally confirms root itself is
+// reachable without crossing a symlink, the same as Open.
 func (fs *nativeFS) Walk(root string, f filepath.WalkFunc) error {
-	root = filepath.Join(string(fs.Dir), root)
+	rel, err := resolvePath(root)
+	if err != nil {
+		return &os.PathError{Op: "walk", Path: root, Err: err}
+	}
+
+	if fs.opt.BeneathRoot {
+		rf, err := fs.openRel(rel, os.O_RDONLY, 0)
+		if err != nil {
+			return err
+		}
+		rf.Close()
+	}
+
+	realRoot := filepath.Join(string(fs.Dir), filepath.FromSlash(rel))
 	f = stripPrefixWalkFunc(f, string(fs.Dir))
 
-	return filepath.Walk(root, f)
+	return filepath.Walk(realRoot, f)
 }
 
 // Fallback returns a FileSystem that tries to perform operations on each given
-// FileSystem in order until it succeeds.
+// FileSystem in order until it succeeds. As with Subdir, the http.File
+// returned by Open comes straight from whichever backend served it, so
+// backend-specific capability interfaces survive the wrapping.
 func Fallback(fs ...FileSystem) FileSystem {
 	return fallbackFS(fs)
 }
@@ -113,6 +141,9 @@ func (fs fallbackFS) Walk(root string, f filepath.WalkFunc) error {
 
 // Subdir returns a FileSystem that is rooted at path within fs. It does not
 // check if the path exists, so errors will occur upon the first usage.
+// Open returns the underlying backend's http.File unchanged, so capability
+// interfaces such as the ones AsFS looks for (io/fs.ReadDirFile and
+// friends) still work after wrapping.
 func Subdir(fs FileSystem, path string) FileSystem {
 	return &subdir{fs, path}
 }
@@ -0,0 +1,676 @@
+package vfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	errIsDirectory = errors.New("is a directory")
+	errNotWritable = errors.New("filesystem is not writable")
+)
+
+// WritableFileSystem extends FileSystem with the write operations needed to
+// serve a read-write tree. The shape mirrors
+// golang.org/x/net/webdav.FileSystem so a WritableFileSystem can sit behind
+// a webdav.Handler, or any other upload handler, without dropping back to
+// the os package directly.
+type WritableFileSystem interface {
+	FileSystem
+
+	OpenFile(name string, flag int, perm os.FileMode) (http.File, error)
+	Mkdir(name string, perm os.FileMode) error
+	MkdirAll(path string, perm os.FileMode) error
+	RemoveAll(name string) error
+	Rename(oldName, newName string) error
+}
+
+// nativeFS write operations. Paths are resolved through resolvePath, the
+// same as Open, then joined onto the root. When fs.opt.BeneathRoot is
+// set, realPath additionally refuses a path that crosses a symlink
+// anywhere along it (via checkBeneath), the same containment Open
+// enforces for reads; Mkdir/MkdirAll/RemoveAll/Rename have no openat2
+// equivalent to make that atomic, so they get the portable per-component
+// check every platform falls back to.
+
+func (fs *nativeFS) realPath(name string) (string, error) {
+	rel, err := resolvePath(name)
+	if err != nil {
+		return "", err
+	}
+	if fs.opt.BeneathRoot {
+		return checkBeneath(string(fs.Dir), rel, fs.opt.FollowSymlinks)
+	}
+	return filepath.Join(string(fs.Dir), filepath.FromSlash(rel)), nil
+}
+
+func (fs *nativeFS) OpenFile(name string, flag int, perm os.FileMode) (http.File, error) {
+	rel, err := resolvePath(name)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+	if !fs.opt.BeneathRoot {
+		return os.OpenFile(filepath.Join(string(fs.Dir), filepath.FromSlash(rel)), flag, perm)
+	}
+	return openBeneath(string(fs.Dir), rel, flag, perm, fs.opt)
+}
+
+func (fs *nativeFS) Mkdir(name string, perm os.FileMode) error {
+	p, err := fs.realPath(name)
+	if err != nil {
+		return &os.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	return os.Mkdir(p, perm)
+}
+
+func (fs *nativeFS) MkdirAll(name string, perm os.FileMode) error {
+	p, err := fs.realPath(name)
+	if err != nil {
+		return &os.PathError{Op: "mkdirall", Path: name, Err: err}
+	}
+	return os.MkdirAll(p, perm)
+}
+
+func (fs *nativeFS) RemoveAll(name string) error {
+	p, err := fs.realPath(name)
+	if err != nil {
+		return &os.PathError{Op: "removeall", Path: name, Err: err}
+	}
+	return os.RemoveAll(p)
+}
+
+func (fs *nativeFS) Rename(oldName, newName string) error {
+	oldPath, err := fs.realPath(oldName)
+	if err != nil {
+		return &os.PathError{Op: "rename", Path: oldName, Err: err}
+	}
+	newPath, err := fs.realPath(newName)
+	if err != nil {
+		return &os.PathError{Op: "rename", Path: newName, Err: err}
+	}
+	return os.Rename(oldPath, newPath)
+}
+
+// subdir write operations: propagate to the wrapped FileSystem when it is
+// writable, translating paths the same way Open and Walk already do.
+
+func (fs *subdir) OpenFile(name string, flag int, perm os.FileMode) (http.File, error) {
+	w, ok := fs.fs.(WritableFileSystem)
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: name, Err: errNotWritable}
+	}
+	return w.OpenFile(filepath.Join(fs.path, name), flag, perm)
+}
+
+func (fs *subdir) Mkdir(name string, perm os.FileMode) error {
+	w, ok := fs.fs.(WritableFileSystem)
+	if !ok {
+		return &os.PathError{Op: "mkdir", Path: name, Err: errNotWritable}
+	}
+	return w.Mkdir(filepath.Join(fs.path, name), perm)
+}
+
+func (fs *subdir) MkdirAll(name string, perm os.FileMode) error {
+	w, ok := fs.fs.(WritableFileSystem)
+	if !ok {
+		return &os.PathError{Op: "mkdirall", Path: name, Err: errNotWritable}
+	}
+	return w.MkdirAll(filepath.Join(fs.path, name), perm)
+}
+
+func (fs *subdir) RemoveAll(name string) error {
+	w, ok := fs.fs.(WritableFileSystem)
+	if !ok {
+		return &os.PathError{Op: "removeall", Path: name, Err: errNotWritable}
+	}
+	return w.RemoveAll(filepath.Join(fs.path, name))
+}
+
+func (fs *subdir) Rename(oldName, newName string) error {
+	w, ok := fs.fs.(WritableFileSystem)
+	if !ok {
+		return &os.PathError{Op: "rename", Path: oldName, Err: errNotWritable}
+	}
+	return w.Rename(filepath.Join(fs.path, oldName), filepath.Join(fs.path, newName))
+}
+
+// fallbackFS write operations: propagate to the first backend in the list
+// that is writable. Unlike Open and Walk, writes are not attempted against
+// more than one backend, since a write that partially succeeds against one
+// backend before falling through to the next would be surprising.
+
+func (fs fallbackFS) firstWritable() (WritableFileSystem, bool) {
+	for _, attempt := range fs {
+		if w, ok := attempt.(WritableFileSystem); ok {
+			return w, true
+		}
+	}
+	return nil, false
+}
+
+func (fs fallbackFS) OpenFile(name string, flag int, perm os.FileMode) (http.File, error) {
+	w, ok := fs.firstWritable()
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: name, Err: errNotWritable}
+	}
+	return w.OpenFile(name, flag, perm)
+}
+
+func (fs fallbackFS) Mkdir(name string, perm os.FileMode) error {
+	w, ok := fs.firstWritable()
+	if !ok {
+		return &os.PathError{Op: "mkdir", Path: name, Err: errNotWritable}
+	}
+	return w.Mkdir(name, perm)
+}
+
+func (fs fallbackFS) MkdirAll(name string, perm os.FileMode) error {
+	w, ok := fs.firstWritable()
+	if !ok {
+		return &os.PathError{Op: "mkdirall", Path: name, Err: errNotWritable}
+	}
+	return w.MkdirAll(name, perm)
+}
+
+func (fs fallbackFS) RemoveAll(name string) error {
+	w, ok := fs.firstWritable()
+	if !ok {
+		return &os.PathError{Op: "removeall", Path: name, Err: errNotWritable}
+	}
+	return w.RemoveAll(name)
+}
+
+func (fs fallbackFS) Rename(oldName, newName string) error {
+	w, ok := fs.firstWritable()
+	if !ok {
+		return &os.PathError{Op: "rename", Path: oldName, Err: errNotWritable}
+	}
+	return w.Rename(oldName, newName)
+}
+
+// NewMemFS returns a WritableFileSystem backed entirely by memory, in the
+// spirit of golang.org/x/net/webdav's memFS: a tree of named nodes where
+// every mutation of a directory's children copies the map first, so a
+// Readdir that is already iterating a directory never observes a torn view
+// of it. Each node locks around its own data and metadata for concurrent
+// reads and writes.
+func NewMemFS() WritableFileSystem {
+	return &memFS{root: &memNode{name: "/", mode: os.ModeDir | 0755, modTime: time.Now(), children: map[string]*memNode{}}}
+}
+
+type memFS struct {
+	root *memNode
+
+	// renameMu serializes Rename so the destination's existence check and
+	// the move happen atomically. Per-node locks aren't enough here:
+	// oldParent and newParent are two different nodes, so checking
+	// existing under newParent's lock and then releasing it before
+	// moving node in would reopen the same check-then-act race Mkdir and
+	// OpenFile close with a single parent lock.
+	renameMu sync.Mutex
+}
+
+type memNode struct {
+	mu       sync.Mutex
+	name     string
+	mode     os.FileMode
+	modTime  time.Time
+	data     []byte
+	children map[string]*memNode // nil for regular files
+}
+
+// os.FileInfo interface
+
+func (n *memNode) Name() string      { return n.name }
+func (n *memNode) Mode() os.FileMode { return n.mode }
+func (n *memNode) IsDir() bool       { return n.children != nil }
+func (n *memNode) Sys() interface{}  { return n }
+
+func (n *memNode) Size() int64 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return int64(len(n.data))
+}
+
+func (n *memNode) ModTime() time.Time {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.modTime
+}
+
+func copyChildren(m map[string]*memNode) map[string]*memNode {
+	c := make(map[string]*memNode, len(m)+1)
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+func splitPath(name string) []string {
+	name = path.Clean("/" + filepath.ToSlash(name))
+	if name == "/" {
+		return nil
+	}
+	return strings.Split(strings.TrimPrefix(name, "/"), "/")
+}
+
+// lookup walks to name and returns its parent directory node, the node
+// itself (nil if it doesn't exist), and its base name. parent is nil only
+// when name is the root. Since the child is read under a lock that is
+// released before the caller acts on it, lookup only gives a snapshot --
+// callers that must check-then-act atomically (create-if-absent,
+// O_EXCL, rename) use lookupParent and hold the parent's lock across
+// both steps instead.
+func (fs *memFS) lookup(name string) (parent, node *memNode, base string, err error) {
+	parent, base, err = fs.lookupParent(name)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if parent == nil {
+		return nil, fs.root, "", nil
+	}
+
+	parent.mu.Lock()
+	node = parent.children[base]
+	parent.mu.Unlock()
+	return parent, node, base, nil
+}
+
+// lookupParent walks to name's parent directory and returns it along with
+// name's base component, without reading the child itself, so a caller
+// can lock the parent once and perform its existence check and mutation
+// in the same critical section. parent is nil only when name is the
+// root.
+func (fs *memFS) lookupParent(name string) (parent *memNode, base string, err error) {
+	comps := splitPath(name)
+	if len(comps) == 0 {
+		return nil, "", nil
+	}
+
+	cur := fs.root
+	for _, c := range comps[:len(comps)-1] {
+		cur.mu.Lock()
+		next, ok := cur.children[c]
+		cur.mu.Unlock()
+		if !ok {
+			return nil, "", os.ErrNotExist
+		}
+		if next.children == nil {
+			return nil, "", errIsFile
+		}
+		cur = next
+	}
+
+	return cur, comps[len(comps)-1], nil
+}
+
+func (fs *memFS) Open(name string) (http.File, error) {
+	return fs.OpenFile(name, os.O_RDONLY, 0)
+}
+
+func (fs *memFS) OpenFile(name string, flag int, perm os.FileMode) (http.File, error) {
+	parent, base, err := fs.lookupParent(name)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	var node *memNode
+	if parent == nil {
+		node = fs.root
+	} else {
+		parent.mu.Lock()
+		node = parent.children[base]
+		if node == nil {
+			if flag&os.O_CREATE == 0 {
+				parent.mu.Unlock()
+				return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+			}
+			node = &memNode{name: base, mode: perm &^ os.ModeDir, modTime: time.Now()}
+			children := copyChildren(parent.children)
+			children[base] = node
+			parent.children = children
+			parent.modTime = time.Now()
+		} else if flag&os.O_EXCL != 0 {
+			parent.mu.Unlock()
+			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrExist}
+		}
+		parent.mu.Unlock()
+	}
+
+	if node.IsDir() {
+		if flag&(os.O_WRONLY|os.O_RDWR) != 0 {
+			return nil, &os.PathError{Op: "open", Path: name, Err: errIsDirectory}
+		}
+		return &memDir{node: node}, nil
+	}
+
+	node.mu.Lock()
+	if flag&os.O_TRUNC != 0 {
+		node.data = nil
+		node.modTime = time.Now()
+	}
+	offset := int64(0)
+	if flag&os.O_APPEND != 0 {
+		offset = int64(len(node.data))
+	}
+	node.mu.Unlock()
+
+	return &memFile{node: node, flag: flag, offset: offset}, nil
+}
+
+func (fs *memFS) Mkdir(name string, perm os.FileMode) error {
+	parent, base, err := fs.lookupParent(name)
+	if err != nil {
+		return &os.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	if parent == nil {
+		return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrExist}
+	}
+
+	parent.mu.Lock()
+	defer parent.mu.Unlock()
+	if _, exists := parent.children[base]; exists {
+		return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrExist}
+	}
+	child := &memNode{name: base, mode: os.ModeDir | perm, modTime: time.Now(), children: map[string]*memNode{}}
+	children := copyChildren(parent.children)
+	children[base] = child
+	parent.children = children
+	parent.modTime = time.Now()
+	return nil
+}
+
+func (fs *memFS) MkdirAll(name string, perm os.FileMode) error {
+	built := ""
+	cur := fs.root
+	for _, c := range splitPath(name) {
+		built = path.Join(built, c)
+		cur.mu.Lock()
+		child, ok := cur.children[c]
+		cur.mu.Unlock()
+		if !ok {
+			if err := fs.Mkdir(built, perm); err != nil && !os.IsExist(err) {
+				return err
+			}
+			cur.mu.Lock()
+			child = cur.children[c]
+			cur.mu.Unlock()
+		} else if child.children == nil {
+			return &os.PathError{Op: "mkdirall", Path: name, Err: errIsFile}
+		}
+		cur = child
+	}
+	return nil
+}
+
+func (fs *memFS) RemoveAll(name string) error {
+	parent, node, base, err := fs.lookup(name)
+	if err != nil {
+		if err == os.ErrNotExist {
+			return nil
+		}
+		return &os.PathError{Op: "removeall", Path: name, Err: err}
+	}
+	if node == nil {
+		return nil
+	}
+	if parent == nil {
+		return &os.PathError{Op: "removeall", Path: name, Err: os.ErrInvalid}
+	}
+
+	parent.mu.Lock()
+	children := copyChildren(parent.children)
+	delete(children, base)
+	parent.children = children
+	parent.modTime = time.Now()
+	parent.mu.Unlock()
+	return nil
+}
+
+func (fs *memFS) Rename(oldName, newName string) error {
+	fs.renameMu.Lock()
+	defer fs.renameMu.Unlock()
+
+	oldParent, node, oldBase, err := fs.lookup(oldName)
+	if err != nil || node == nil {
+		return &os.PathError{Op: "rename", Path: oldName, Err: os.ErrNotExist}
+	}
+	newParent, existing, newBase, err := fs.lookup(newName)
+	if err != nil {
+		return &os.PathError{Op: "rename", Path: newName, Err: err}
+	}
+	if existing != nil {
+		return &os.PathError{Op: "rename", Path: newName, Err: os.ErrExist}
+	}
+	if oldParent == nil || newParent == nil {
+		return &os.PathError{Op: "rename", Path: newName, Err: os.ErrInvalid}
+	}
+
+	newParent.mu.Lock()
+	if _, exists := newParent.children[newBase]; exists {
+		// Something (Mkdir, OpenFile with O_CREATE) created newBase
+		// after the lookup above but before we got here; re-check under
+		// the same lock those operations insert under instead of
+		// clobbering it, before the source has been touched.
+		newParent.mu.Unlock()
+		return &os.PathError{Op: "rename", Path: newName, Err: os.ErrExist}
+	}
+	nc := copyChildren(newParent.children)
+	if newParent == oldParent {
+		delete(nc, oldBase)
+	}
+	nc[newBase] = node
+	newParent.children = nc
+	newParent.modTime = time.Now()
+	newParent.mu.Unlock()
+
+	if oldParent != newParent {
+		oldParent.mu.Lock()
+		oc := copyChildren(oldParent.children)
+		delete(oc, oldBase)
+		oldParent.children = oc
+		oldParent.modTime = time.Now()
+		oldParent.mu.Unlock()
+	}
+
+	node.mu.Lock()
+	node.name = newBase
+	node.mu.Unlock()
+	return nil
+}
+
+func (fs *memFS) Walk(root string, fn filepath.WalkFunc) error {
+	_, node, _, err := fs.lookup(root)
+	if err != nil {
+		return &os.PathError{Op: "walk", Path: root, Err: err}
+	}
+	if node == nil {
+		return &os.PathError{Op: "walk", Path: root, Err: os.ErrNotExist}
+	}
+	return walkMemNode(root, node, fn)
+}
+
+func walkMemNode(p string, node *memNode, fn filepath.WalkFunc) error {
+	if err := fn(p, node, nil); err != nil {
+		return err
+	}
+	if !node.IsDir() {
+		return nil
+	}
+	node.mu.Lock()
+	children := node.children
+	node.mu.Unlock()
+	for name, child := range children {
+		if err := walkMemNode(path.Join(p, name), child, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// memFile is a handle onto a regular file's data. Its own offset is not
+// shared with other handles on the same node, but reads and writes of the
+// underlying data are synchronized through the node's mutex.
+type memFile struct {
+	node   *memNode
+	flag   int
+	offset int64
+}
+
+func (f *memFile) Read(p []byte) (int, error) {
+	if f.flag&os.O_WRONLY != 0 {
+		return 0, &os.PathError{Op: "read", Path: f.node.name, Err: os.ErrInvalid}
+	}
+	f.node.mu.Lock()
+	defer f.node.mu.Unlock()
+	if f.offset >= int64(len(f.node.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.node.data[f.offset:])
+	f.offset += int64(n)
+	return n, nil
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	if f.flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return 0, &os.PathError{Op: "write", Path: f.node.name, Err: os.ErrInvalid}
+	}
+	f.node.mu.Lock()
+	defer f.node.mu.Unlock()
+	if f.flag&os.O_APPEND != 0 {
+		f.offset = int64(len(f.node.data))
+	}
+	end := f.offset + int64(len(p))
+	if end > int64(len(f.node.data)) {
+		grown := make([]byte, end)
+		copy(grown, f.node.data)
+		f.node.data = grown
+	}
+	n := copy(f.node.data[f.offset:end], p)
+	f.offset += int64(n)
+	f.node.modTime = time.Now()
+	return n, nil
+}
+
+func (f *memFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+		f.offset = offset
+	case io.SeekCurrent:
+		f.offset += offset
+	case io.SeekEnd:
+		f.node.mu.Lock()
+		f.offset = int64(len(f.node.data)) + offset
+		f.node.mu.Unlock()
+	default:
+		return 0, &os.PathError{Op: "seek", Path: f.node.name, Err: os.ErrInvalid}
+	}
+	return f.offset, nil
+}
+
+func (f *memFile) Close() error { return nil }
+
+func (f *memFile) Readdir(count int) ([]os.FileInfo, error) {
+	return nil, &os.PathError{Op: "readdir", Path: f.node.name, Err: errIsFile}
+}
+
+func (f *memFile) Stat() (os.FileInfo, error) { return f.node, nil }
+
+// memDir is a handle onto a directory node. Its listing is a sorted
+// snapshot of the node's children taken on first read, with an offset
+// advanced by each Readdir/ReadDir call, mirroring os.File's Readdir
+// cursor and EOF conventions: a count <= 0 returns everything remaining
+// (nothing, once exhausted) while a count > 0 returns io.EOF once
+// nothing is left.
+type memDir struct {
+	node *memNode
+
+	mu     sync.Mutex
+	names  []string
+	loaded bool
+	offset int
+}
+
+func (d *memDir) Read(p []byte) (int, error) {
+	return 0, &os.PathError{Op: "read", Path: d.node.name, Err: errIsDirectory}
+}
+
+func (d *memDir) Seek(offset int64, whence int) (int64, error) {
+	return 0, &os.PathError{Op: "seek", Path: d.node.name, Err: errIsDirectory}
+}
+
+func (d *memDir) Close() error { return nil }
+
+func (d *memDir) Stat() (os.FileInfo, error) { return d.node, nil }
+
+func (d *memDir) snapshot() {
+	if d.loaded {
+		return
+	}
+	d.node.mu.Lock()
+	names := make([]string, 0, len(d.node.children))
+	for name := range d.node.children {
+		names = append(names, name)
+	}
+	d.node.mu.Unlock()
+	sort.Strings(names)
+	d.names = names
+	d.loaded = true
+}
+
+func (d *memDir) readdir(count int) ([]*memNode, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.snapshot()
+
+	remaining := len(d.names) - d.offset
+	n := count
+	if n <= 0 || n > remaining {
+		n = remaining
+	}
+	names := d.names[d.offset : d.offset+n]
+	d.offset += n
+
+	d.node.mu.Lock()
+	nodes := make([]*memNode, 0, len(names))
+	for _, name := range names {
+		if c, ok := d.node.children[name]; ok {
+			nodes = append(nodes, c)
+		}
+	}
+	d.node.mu.Unlock()
+
+	if count > 0 && len(nodes) == 0 {
+		return nodes, io.EOF
+	}
+	return nodes, nil
+}
+
+func (d *memDir) Readdir(count int) ([]os.FileInfo, error) {
+	nodes, err := d.readdir(count)
+	infos := make([]os.FileInfo, len(nodes))
+	for i, n := range nodes {
+		infos[i] = n
+	}
+	return infos, err
+}
+
+// ReadDir implements fs.ReadDirFile so memDir keeps its listing capability
+// through vfs.AsFS, matching what the bindata backend offers.
+func (d *memDir) ReadDir(count int) ([]fs.DirEntry, error) {
+	nodes, err := d.readdir(count)
+	entries := make([]fs.DirEntry, len(nodes))
+	for i, n := range nodes {
+		entries[i] = fs.FileInfoToDirEntry(n)
+	}
+	return entries, err
+}
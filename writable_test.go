@@ -0,0 +1,262 @@
+package vfs
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func writeFile(t *testing.T, w WritableFileSystem, name string, flag int, data string) {
+	t.Helper()
+	f, err := w.OpenFile(name, flag, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile(%q): %v", name, err)
+	}
+	defer f.Close()
+	ww, ok := f.(io.Writer)
+	if !ok {
+		t.Fatalf("OpenFile(%q) did not return a writable file", name)
+	}
+	if _, err := ww.Write([]byte(data)); err != nil {
+		t.Fatalf("Write(%q): %v", name, err)
+	}
+}
+
+func readFile(t *testing.T, w WritableFileSystem, name string) string {
+	t.Helper()
+	f, err := w.Open(name)
+	if err != nil {
+		t.Fatalf("Open(%q): %v", name, err)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatalf("ReadAll(%q): %v", name, err)
+	}
+	return string(data)
+}
+
+func memAndNative(t *testing.T) []struct {
+	name string
+	fs   WritableFileSystem
+} {
+	t.Helper()
+	nat, err := Native(t.TempDir(), ResolveOpt{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	natWritable, ok := nat.(WritableFileSystem)
+	if !ok {
+		t.Fatal("Native-backed FileSystem does not implement WritableFileSystem")
+	}
+	return []struct {
+		name string
+		fs   WritableFileSystem
+	}{
+		{"memFS", NewMemFS()},
+		{"nativeFS", natWritable},
+	}
+}
+
+func TestWritableFileSystemBasics(t *testing.T) {
+	for _, tc := range memAndNative(t) {
+		t.Run(tc.name, func(t *testing.T) {
+			w := tc.fs
+
+			writeFile(t, w, "a.txt", os.O_CREATE|os.O_WRONLY, "hello")
+			if got := readFile(t, w, "a.txt"); got != "hello" {
+				t.Errorf("read back %q, want %q", got, "hello")
+			}
+
+			if err := w.Mkdir("sub", 0755); err != nil {
+				t.Fatalf("Mkdir: %v", err)
+			}
+			writeFile(t, w, "sub/b.txt", os.O_CREATE|os.O_WRONLY, "world")
+			if got := readFile(t, w, "sub/b.txt"); got != "world" {
+				t.Errorf("read back %q, want %q", got, "world")
+			}
+
+			if err := w.MkdirAll("x/y/z", 0755); err != nil {
+				t.Fatalf("MkdirAll: %v", err)
+			}
+			if _, err := w.Open("x/y/z"); err != nil {
+				t.Fatalf("Open(x/y/z) after MkdirAll: %v", err)
+			}
+
+			if err := w.Rename("a.txt", "renamed.txt"); err != nil {
+				t.Fatalf("Rename: %v", err)
+			}
+			if _, err := w.Open("a.txt"); err == nil {
+				t.Error("Open(a.txt) succeeded after Rename, want error")
+			}
+			if got := readFile(t, w, "renamed.txt"); got != "hello" {
+				t.Errorf("read back renamed file %q, want %q", got, "hello")
+			}
+
+			if err := w.RemoveAll("sub"); err != nil {
+				t.Fatalf("RemoveAll: %v", err)
+			}
+			if _, err := w.Open("sub/b.txt"); err == nil {
+				t.Error("Open(sub/b.txt) succeeded after RemoveAll(sub), want error")
+			}
+		})
+	}
+}
+
+func TestWritableFileSystemOAppendOTrunc(t *testing.T) {
+	for _, tc := range memAndNative(t) {
+		t.Run(tc.name, func(t *testing.T) {
+			w := tc.fs
+			writeFile(t, w, "f.txt", os.O_CREATE|os.O_WRONLY, "abc")
+			writeFile(t, w, "f.txt", os.O_WRONLY|os.O_APPEND, "def")
+			if got := readFile(t, w, "f.txt"); got != "abcdef" {
+				t.Errorf("after append, got %q, want %q", got, "abcdef")
+			}
+
+			writeFile(t, w, "f.txt", os.O_WRONLY|os.O_TRUNC, "xyz")
+			if got := readFile(t, w, "f.txt"); got != "xyz" {
+				t.Errorf("after truncate, got %q, want %q", got, "xyz")
+			}
+		})
+	}
+}
+
+func TestWritableFileSystemOExclConflict(t *testing.T) {
+	for _, tc := range memAndNative(t) {
+		t.Run(tc.name, func(t *testing.T) {
+			w := tc.fs
+			writeFile(t, w, "f.txt", os.O_CREATE|os.O_EXCL|os.O_WRONLY, "first")
+
+			_, err := w.OpenFile("f.txt", os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+			if !os.IsExist(err) {
+				t.Errorf("second OpenFile O_EXCL = %v, want os.ErrExist", err)
+			}
+		})
+	}
+}
+
+func TestWritableFileSystemMkdirExistsConflict(t *testing.T) {
+	for _, tc := range memAndNative(t) {
+		t.Run(tc.name, func(t *testing.T) {
+			w := tc.fs
+			if err := w.Mkdir("d", 0755); err != nil {
+				t.Fatalf("first Mkdir: %v", err)
+			}
+			if err := w.Mkdir("d", 0755); !os.IsExist(err) {
+				t.Errorf("second Mkdir(d) = %v, want os.ErrExist", err)
+			}
+		})
+	}
+}
+
+// TestWritableFileSystemRenameDestinationExistsConflict is memFS-only:
+// nativeFS.Rename delegates straight to os.Rename, which follows POSIX
+// rename(2) semantics and silently replaces an existing destination.
+// memFS instead mirrors the explicit-conflict contract the rest of its
+// write operations use (Mkdir, OpenFile with O_EXCL), so a Rename onto
+// an existing name must fail with os.ErrExist rather than clobber it.
+func TestWritableFileSystemRenameDestinationExistsConflict(t *testing.T) {
+	w := NewMemFS()
+	writeFile(t, w, "a.txt", os.O_CREATE|os.O_WRONLY, "a")
+	writeFile(t, w, "b.txt", os.O_CREATE|os.O_WRONLY, "b")
+
+	if err := w.Rename("a.txt", "b.txt"); !os.IsExist(err) {
+		t.Errorf("Rename onto existing destination = %v, want os.ErrExist", err)
+	}
+	if got := readFile(t, w, "b.txt"); got != "b" {
+		t.Errorf("destination clobbered: got %q, want %q", got, "b")
+	}
+}
+
+// TestMemFSMkdirConcurrentCreate exercises the check-then-act race fixed
+// in df36df3: two concurrent Mkdir calls for the same name must result
+// in exactly one success and one os.ErrExist, never both succeeding.
+func TestMemFSMkdirConcurrentCreate(t *testing.T) {
+	w := NewMemFS()
+
+	const n = 20
+	var wg sync.WaitGroup
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = w.Mkdir("race", 0755)
+		}(i)
+	}
+	wg.Wait()
+
+	successes := 0
+	for _, err := range errs {
+		if err == nil {
+			successes++
+		} else if !os.IsExist(err) {
+			t.Errorf("Mkdir returned unexpected error: %v", err)
+		}
+	}
+	if successes != 1 {
+		t.Errorf("%d concurrent Mkdir(race) calls succeeded, want exactly 1", successes)
+	}
+}
+
+// TestMemFSRenameVsOpenFileCreate exercises the cross-operation race
+// fixed in this round: a Rename onto newName racing with a concurrent
+// OpenFile(newName, O_CREATE|O_EXCL) must never result in both
+// succeeding, nor in Rename silently clobbering the file OpenFile just
+// created.
+func TestMemFSRenameVsOpenFileCreate(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		w := NewMemFS()
+		writeFile(t, w, "src.txt", os.O_CREATE|os.O_WRONLY, "src")
+
+		var wg sync.WaitGroup
+		var renameErr, openErr error
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			renameErr = w.Rename("src.txt", "dst.txt")
+		}()
+		go func() {
+			defer wg.Done()
+			f, err := w.OpenFile("dst.txt", os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+			if err == nil {
+				f.Close()
+			}
+			openErr = err
+		}()
+		wg.Wait()
+
+		if renameErr == nil && openErr == nil {
+			t.Fatalf("iteration %d: both Rename and OpenFile(O_EXCL) succeeded for the same destination", i)
+		}
+	}
+}
+
+func TestNativeWritableBeneathRootSymlinkRename(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	if err := os.Mkdir(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "secret"), []byte("s"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "secret"), filepath.Join(root, "link")); err != nil {
+		t.Skipf("could not create symlink: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fsys, err := Native(root, ResolveOpt{BeneathRoot: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := fsys.(WritableFileSystem)
+
+	if err := w.Rename("a.txt", "link"); err == nil {
+		t.Error("Rename onto a symlink escaping root succeeded, want error")
+	}
+}